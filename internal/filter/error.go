@@ -0,0 +1,120 @@
+/*
+Copyright (c) 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError is the error returned by the parser when the filter expression contains a syntax
+// error. In addition to the usual textual message it carries the exact position where the
+// problem was found, so that calling code can render rich diagnostics that point at the offending
+// text, for example when reporting a bad `filter` query parameter back to the client.
+type SyntaxError struct {
+	// Source is the complete text of the filter expression that was being parsed.
+	Source string
+
+	// Line is the one based line, inside Source, where the offending token starts.
+	Line int
+
+	// Column is the one based column, counted in runes, where the offending token starts.
+	Column int
+
+	// Start and End are the zero based byte offsets, inside Source, of the offending token. When
+	// the token is empty, as happens at the end of the input, Start and End are equal.
+	Start int
+	End   int
+
+	// message is the description of the problem, without the source line and the caret.
+	message string
+}
+
+// Error generates the text of the error. It contains the description of the problem preceded by
+// the offending source line and a line with a caret that points at the exact position of the
+// problem, for example:
+//
+//	(eq,myattr,'myvalue'
+//	                    ^ expected comma or right parenthesis, got end of input
+func (e *SyntaxError) Error() string {
+	line := e.sourceLine()
+	indent := e.indent(line)
+	underline := e.underline()
+	return fmt.Sprintf("%s\n%s%s %s", line, indent, underline, e.message)
+}
+
+// sourceLine returns the line of the source that contains the offending token.
+func (e *SyntaxError) sourceLine() string {
+	lines := strings.Split(e.Source, "\n")
+	if e.Line < 1 || e.Line > len(lines) {
+		return ""
+	}
+	return lines[e.Line-1]
+}
+
+// indent returns the text that should precede the underline so that it aligns under the offending
+// token. It reproduces the tabs of the source line so that the caret also aligns in a terminal.
+func (e *SyntaxError) indent(line string) string {
+	runes := []rune(line)
+	width := e.Column - 1
+	if width < 0 {
+		width = 0
+	}
+	result := make([]rune, 0, width)
+	for i := 0; i < width && i < len(runes); i++ {
+		if runes[i] == '\t' {
+			result = append(result, '\t')
+		} else {
+			result = append(result, ' ')
+		}
+	}
+	return string(result)
+}
+
+// underline returns the run of dashes and the final caret that point at the offending token. It
+// spans the whole width of the token, except for the empty tokens used to represent the end of
+// the input, which get a single caret.
+func (e *SyntaxError) underline() string {
+	width := e.End - e.Start
+	if e.Start >= 0 && e.Start <= e.End && e.End <= len(e.Source) {
+		width = utf8.RuneCountInString(e.Source[e.Start:e.End])
+	}
+	if width < 1 {
+		width = 1
+	}
+	return strings.Repeat("-", width-1) + "^"
+}
+
+// SyntaxErrorList is the list of syntax errors found while parsing a filter expression. Parser.Parse
+// returns it, instead of a single SyntaxError, so that callers can report every problem found in
+// the expression instead of just the first one.
+type SyntaxErrorList []*SyntaxError
+
+// Error joins the text of all the errors in the list, one per line.
+func (l SyntaxErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	default:
+		texts := make([]string, len(l))
+		for i, err := range l {
+			texts[i] = err.Error()
+		}
+		return strings.Join(texts, "\n")
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright (c) 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operatorText returns the canonical textual representation of the given operator, the same text
+// that parseOperator accepts to produce it.
+func operatorText(operator Operator) string {
+	switch operator {
+	case Cont:
+		return "cont"
+	case Eq:
+		return "eq"
+	case Gt:
+		return "gt"
+	case Gte:
+		return "gte"
+	case In:
+		return "in"
+	case Lt:
+		return "lt"
+	case Ncont:
+		return "ncont"
+	case Neq:
+		return "neq"
+	case Nin:
+		return "nin"
+	default:
+		return fmt.Sprintf("%v", operator)
+	}
+}
+
+// Expr is a node of the abstract syntax tree produced by parsing a filter expression. Exactly one
+// of its fields is populated, and which one indicates the kind of node, except in the empty
+// expression that Parse returns when none of the terms is well formed:
+//
+//   - Term is populated when the expression is a single term, the leaf of the tree.
+//   - Terms is populated when the expression is a conjunction of terms joined with `;` and none of
+//     them use disjunction, negation or explicit grouping. This is the shape produced for every
+//     expression accepted before boolean composition was added, and is kept so that code written
+//     against that shape keeps working unchanged.
+//   - And is populated when the expression is a conjunction, joined with `;`, that combines two or
+//     more sub-expressions at least one of which isn't a plain term.
+//   - Or is populated when the expression is a disjunction of two or more sub-expressions, joined
+//     with `|`.
+//   - Not is populated when the expression is the negation, introduced with `!`, of a single
+//     sub-expression.
+//   - Group is populated when the expression is a parenthesized sub-expression that doesn't fall
+//     into any of the cases above. String renders it as the expression that it wraps, without
+//     parentheses of its own, since it always emits the minimal parenthesization required by
+//     operator precedence, but it is kept so that parsing doesn't discard information about the
+//     structure of the source text.
+type Expr struct {
+	Term  *Term
+	Terms []*Term
+	And   []*Expr
+	Or    []*Expr
+	Not   *Expr
+	Group *Expr
+}
+
+// exprPrecedence orders the kinds of expression from the loosest binding, disjunction, to the
+// tightest, a single term, so that String can decide when parentheses are required.
+type exprPrecedence int
+
+const (
+	exprPrecedenceOr exprPrecedence = iota
+	exprPrecedenceAnd
+	exprPrecedenceNot
+	exprPrecedenceAtom
+)
+
+// precedence returns the precedence of the expression, following Group nodes through to the
+// expression that they wrap.
+func (e *Expr) precedence() exprPrecedence {
+	switch {
+	case e.Or != nil:
+		return exprPrecedenceOr
+	case e.And != nil, e.Terms != nil:
+		return exprPrecedenceAnd
+	case e.Not != nil:
+		return exprPrecedenceNot
+	case e.Group != nil:
+		return e.Group.precedence()
+	default:
+		return exprPrecedenceAtom
+	}
+}
+
+// String generates the canonical text representation of the expression, using the minimal
+// parenthesization required for the result to parse back into an equivalent tree.
+func (e *Expr) String() string {
+	switch {
+	case e.Term != nil:
+		return e.Term.String()
+	case e.Terms != nil:
+		parts := make([]string, len(e.Terms))
+		for i, term := range e.Terms {
+			parts[i] = term.String()
+		}
+		return strings.Join(parts, ";")
+	case e.And != nil:
+		parts := make([]string, len(e.And))
+		for i, operand := range e.And {
+			parts[i] = operandString(operand, exprPrecedenceAnd)
+		}
+		return strings.Join(parts, ";")
+	case e.Or != nil:
+		parts := make([]string, len(e.Or))
+		for i, operand := range e.Or {
+			parts[i] = operandString(operand, exprPrecedenceOr)
+		}
+		return strings.Join(parts, "|")
+	case e.Not != nil:
+		return "!" + operandString(e.Not, exprPrecedenceNot)
+	case e.Group != nil:
+		return e.Group.String()
+	default:
+		return ""
+	}
+}
+
+// operandString renders the given operand, adding parentheses around it only when its precedence
+// is lower than the one required by the context that it appears in.
+func operandString(operand *Expr, context exprPrecedence) string {
+	text := operand.String()
+	if operand.precedence() < context {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// String generates the canonical text representation of the term, for example
+// `(eq,myattr,'myvalue')`.
+func (t *Term) String() string {
+	var b strings.Builder
+	b.WriteString("(")
+	b.WriteString(operatorText(t.Operator))
+	b.WriteString(",")
+	b.WriteString(pathString(t.Path))
+	b.WriteString(",")
+	for i, value := range t.Values {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(valueString(value))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// pathString generates the canonical text representation of a path, joining its segments with `/`,
+// except that a bracket segment, the wildcard or keyed filter that follows a field name, is never
+// preceded by a slash, matching what parsePath accepts.
+func pathString(segments []PathSegment) string {
+	var b strings.Builder
+	for i, segment := range segments {
+		if i > 0 && segment.Key == "" && !segment.Wildcard {
+			b.WriteString("/")
+		}
+		b.WriteString(segment.String())
+	}
+	return b.String()
+}
+
+// valueString generates the canonical text representation of a term value, a single quoted string
+// with the `'` and `\` characters escaped with a leading backslash.
+func valueString(value any) string {
+	text, ok := value.(string)
+	if !ok {
+		text = ""
+	}
+	var b strings.Builder
+	b.WriteString("'")
+	for _, r := range text {
+		switch r {
+		case '\'', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString("'")
+	return b.String()
+}
@@ -0,0 +1,731 @@
+/*
+Copyright (c) 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"unicode"
+)
+
+// LexerBuilder contains the data and logic needed to create a new lexical scanner for filter
+// expressions. Don't create instances of this directly, use the NewLexer function instead.
+type LexerBuilder struct {
+	logger *slog.Logger
+	source string
+}
+
+// Lexer is a lexical scanner for the filter expression language. Internally it scans the source in
+// a separate goroutine, modeled after the state function pattern described in Rob Pike's "Lexical
+// Scanning in Go" talk, and delivers the resulting tokens through a channel. Don't create instances
+// of this type directly, use the NewLexer function instead.
+type Lexer struct {
+	logger *slog.Logger
+	source string
+	buffer *bytes.Buffer
+	mode   exprMode
+	pos    exprPosition
+	prev   exprPosition
+
+	ctx     context.Context
+	driven  bool
+	started bool
+	tokens  chan Token
+	next    chan struct{}
+	stopped chan struct{}
+}
+
+// exprMode selects which set of tokens the lexer recognizes. The grammar uses a different set of
+// tokens inside the parenthesis that contain the values of a term than it uses everywhere else, so
+// the parser switches the mode of the lexer before and after parsing those values.
+type exprMode int
+
+const (
+	// exprDefaultMode is the mode used to recognize operators, paths and the punctuation that
+	// separates terms.
+	exprDefaultMode exprMode = iota
+
+	// exprValuesMode is the mode used to recognize the quoted strings that are the values of a
+	// term.
+	exprValuesMode
+
+	// exprBracketMode is the mode used to recognize the contents of the array filtering
+	// segments of a path, for example the `resourceTypeID:CPU-*` inside
+	// `resources[resourceTypeID:CPU-*]`.
+	exprBracketMode
+)
+
+// Symbol represents the terminal symbols of the filter expression language.
+type Symbol int
+
+const (
+	SymbolEnd Symbol = iota
+	SymbolIdentifier
+	SymbolString
+	SymbolComma
+	SymbolSlash
+	SymbolSemicolon
+	SymbolLeftParenthesis
+	SymbolRightParenthesis
+	SymbolLeftBracket
+	SymbolRightBracket
+	SymbolColon
+	SymbolStar
+	SymbolBang
+	SymbolPipe
+	SymbolError
+)
+
+// String generates a human readable description of the terminal symbol, suitable for use inside
+// error messages like `expected <symbol>`.
+func (s Symbol) String() string {
+	switch s {
+	case SymbolEnd:
+		return "end of input"
+	case SymbolIdentifier:
+		return "identifier"
+	case SymbolString:
+		return "string"
+	case SymbolComma:
+		return "comma"
+	case SymbolSlash:
+		return "slash"
+	case SymbolSemicolon:
+		return "semicolon"
+	case SymbolLeftParenthesis:
+		return "left parenthesis"
+	case SymbolRightParenthesis:
+		return "right parenthesis"
+	case SymbolLeftBracket:
+		return "left bracket"
+	case SymbolRightBracket:
+		return "right bracket"
+	case SymbolColon:
+		return "colon"
+	case SymbolStar:
+		return "asterisk"
+	case SymbolBang:
+		return "bang"
+	case SymbolPipe:
+		return "pipe"
+	case SymbolError:
+		return "error"
+	default:
+		return fmt.Sprintf("unknown symbol %d", s)
+	}
+}
+
+// exprPosition identifies the location of a rune inside the source text.
+type exprPosition struct {
+	// Offset is the zero based byte offset of the rune.
+	Offset int
+
+	// Line is the one based line number of the rune.
+	Line int
+
+	// Column is the one based column number of the rune, counted in runes.
+	Column int
+}
+
+// Token represents the tokens returned by the lexical scanner. Each token contains the
+// terminal symbol, its text, the position of its first rune inside the source and the position
+// right after its last rune. A
+// token whose Symbol is SymbolError doesn't represent a real terminal symbol: it carries, in
+// Text, the message of a lexical error found at the given position, so that errors can flow
+// through the same channel as ordinary tokens instead of needing a side channel of their own.
+type Token struct {
+	Symbol Symbol
+	Text   string
+	Start  exprPosition
+	End    exprPosition
+}
+
+// String generates a string representation of the token, suitable for use inside error messages
+// like `got <token>`.
+func (t *Token) String() string {
+	if t == nil {
+		return "nil"
+	}
+	switch t.Symbol {
+	case SymbolIdentifier, SymbolString:
+		return fmt.Sprintf("%s '%s'", t.Symbol, t.Text)
+	default:
+		return t.Symbol.String()
+	}
+}
+
+// NewLexer creates a builder that can then be used to configure and create lexers.
+func NewLexer() *LexerBuilder {
+	return &LexerBuilder{}
+}
+
+// SetLogger sets the logger that the lexer will use to write log messages. This is mandatory.
+func (b *LexerBuilder) SetLogger(value *slog.Logger) *LexerBuilder {
+	b.logger = value
+	return b
+}
+
+// SetSource sets the source string to parse. This is mandatory.
+func (b *LexerBuilder) SetSource(value string) *LexerBuilder {
+	b.source = value
+	return b
+}
+
+// Build uses the data stored in the builder to create a new lexer. The lexer doesn't start
+// scanning until Start or Tokens is called.
+func (b *LexerBuilder) Build() (result *Lexer, err error) {
+	// Check parameters:
+	if b.logger == nil {
+		err = errors.New("logger is mandatory")
+		return
+	}
+	if len(b.source) == 0 {
+		err = errors.New("source is mandatory")
+		return
+	}
+
+	// Create and populate the object:
+	result = &Lexer{
+		logger: b.logger,
+		source: b.source,
+		buffer: bytes.NewBufferString(b.source),
+		pos: exprPosition{
+			Line:   1,
+			Column: 1,
+		},
+		tokens:  make(chan Token, 1),
+		next:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	return
+}
+
+// SetMode changes the set of tokens that the lexer recognizes. The parser calls this to switch
+// between the default mode, used for operators and paths, and the values mode, used for the
+// quoted strings that are the values of a term. It is only safe to call this between a call to
+// FetchToken and the next one, so that the scanning goroutine, which may already be waiting for
+// permission to continue, picks up the new mode before producing the following token.
+func (l *Lexer) SetMode(value exprMode) {
+	l.mode = value
+}
+
+// Start launches the goroutine that scans the source and synchronizes it with FetchToken, so that
+// it never scans more than one token ahead of what has already been requested. This keeps calls to
+// SetMode in step with the token that they are meant to affect. The context is used to make sure
+// that the goroutine always terminates, even if the parser stops fetching tokens before reaching
+// the end of the input, for example because a syntax error ended the parse early.
+func (l *Lexer) Start(ctx context.Context) {
+	l.ctx = ctx
+	l.driven = true
+	l.started = true
+	go l.run()
+}
+
+// Tokens starts scanning the source, if it hasn't been started already, and returns the channel
+// that the resulting tokens are sent to. Unlike FetchToken, it doesn't wait for permission to
+// produce each token, so it scans freely ahead at its own pace. It lets tools tokenize a filter
+// expression without driving a parser, for example a syntax highlighter: in that case a quote in
+// the default mode starts a value string, but the contents of array filtering segments are still
+// scanned with the default rules, so a colon inside them is delivered as a SymbolError token. It
+// should not be used together with Start, since nothing would then keep SetMode synchronized with
+// the token it affects.
+func (l *Lexer) Tokens() <-chan Token {
+	if !l.started {
+		l.ctx = context.Background()
+		l.started = true
+		go l.run()
+	}
+	return l.tokens
+}
+
+// run is the body of the scanning goroutine. It repeatedly calls the current state function until
+// one of them returns nil, which happens once the end of the input or a lexical error has been
+// reached, or the context passed to Start has been cancelled.
+func (l *Lexer) run() {
+	defer close(l.tokens)
+	defer close(l.stopped)
+	state := stateFn(dispatchState)
+	for state != nil {
+		state = state(l)
+	}
+}
+
+// stateFn is the type of the functions that implement the states of the lexer's scanning state
+// machine. Each one does some combination of waiting for permission to continue, sending a token
+// and deciding which mode to scan next in, and returns the function that implements the following
+// state, or nil once there is nothing left to do.
+type stateFn func(*Lexer) stateFn
+
+// dispatchState waits, when the lexer is being driven by FetchToken, for permission to scan
+// another token, and then selects the state function that corresponds to the current mode. Moving
+// this choice into its own state, run between every pair of tokens, is what guarantees that a call
+// to SetMode always takes effect before the next token is scanned, instead of racing against a
+// state function that already decided, ahead of time, which mode to use next.
+func dispatchState(l *Lexer) stateFn {
+	if l.driven {
+		select {
+		case <-l.next:
+		case <-l.ctx.Done():
+			return nil
+		}
+	}
+	switch l.mode {
+	case exprValuesMode:
+		return lexValue
+	case exprBracketMode:
+		return lexBracket
+	default:
+		return lexDefault
+	}
+}
+
+// send delivers the given token to whoever is reading the tokens channel, or gives up if the
+// context passed to Start is cancelled first. It returns false in that case, so that the calling
+// state function can stop scanning instead of trying to send another token that nobody will ever
+// receive.
+func (l *Lexer) send(token Token) bool {
+	select {
+	case l.tokens <- token:
+		return true
+	case <-l.ctx.Done():
+		return false
+	}
+}
+
+// FetchToken asks the scanning goroutine for the next token and waits for it. If the goroutine
+// reports a lexical error it is translated into a SyntaxError, exactly as a token fetched the old,
+// synchronous way would have been.
+func (l *Lexer) FetchToken() (token *Token, err error) {
+	select {
+	case l.next <- struct{}{}:
+	case <-l.ctx.Done():
+		return nil, l.ctx.Err()
+	case <-l.stopped:
+		return &Token{Symbol: SymbolEnd}, nil
+	}
+	select {
+	case token, ok := <-l.tokens:
+		if !ok {
+			return &Token{Symbol: SymbolEnd}, nil
+		}
+		if token.Symbol == SymbolError {
+			return nil, l.errorf(token.Start, token.End, "%s", token.Text)
+		}
+		return &token, nil
+	case <-l.ctx.Done():
+		return nil, l.ctx.Err()
+	}
+}
+
+// lexDefault scans the next token while recognizing operators, paths and the punctuation that
+// separates and combines terms, including the `!` and `|` used for boolean composition.
+func lexDefault(l *Lexer) stateFn {
+	type State int
+	const (
+		S0 State = iota
+		S1
+	)
+	state := S0
+	lexeme := &bytes.Buffer{}
+	var start exprPosition
+	var identStart exprPosition
+	for {
+		start = l.pos
+		r := l.readRune()
+		switch state {
+		case S0:
+			switch {
+			case unicode.IsSpace(r):
+				state = S0
+			case unicode.IsLetter(r) || r == '_':
+				identStart = start
+				lexeme.WriteRune(r)
+				state = S1
+			case r == ',':
+				if !l.send(Token{Symbol: SymbolComma, Text: ",", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == '/':
+				if !l.send(Token{Symbol: SymbolSlash, Text: "/", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == ';':
+				if !l.send(Token{Symbol: SymbolSemicolon, Text: ";", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == '(':
+				if !l.send(Token{Symbol: SymbolLeftParenthesis, Text: "(", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == ')':
+				if !l.send(Token{Symbol: SymbolRightParenthesis, Text: ")", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == '[':
+				if !l.send(Token{Symbol: SymbolLeftBracket, Text: "[", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == '!':
+				if !l.send(Token{Symbol: SymbolBang, Text: "!", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == '|':
+				if !l.send(Token{Symbol: SymbolPipe, Text: "|", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == '\'' && !l.driven:
+				// Without a parser to switch modes, a quote starts a value string.
+				l.unreadRune()
+				return lexValue
+			case r == 0:
+				l.send(Token{Symbol: SymbolEnd, Start: start, End: start})
+				return nil
+			default:
+				l.send(Token{
+					Symbol: SymbolError,
+					Text: fmt.Sprintf(
+						"unexpected character '%c' while expecting start of identifier", r,
+					),
+					Start: start,
+					End:   l.pos,
+				})
+				return nil
+			}
+		case S1:
+			switch {
+			case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+				lexeme.WriteRune(r)
+				state = S1
+			default:
+				l.unreadRune()
+				if !l.send(Token{
+					Symbol: SymbolIdentifier,
+					Text:   lexeme.String(),
+					Start:  identStart,
+					End:    l.pos,
+				}) {
+					return nil
+				}
+				return dispatchState
+			}
+		}
+	}
+}
+
+// lexValue scans the next token while recognizing the quoted strings that are the values of a
+// term, plus the comma and right parenthesis that separate and close them.
+func lexValue(l *Lexer) stateFn {
+	type State int
+	const (
+		S0 State = iota
+		S1
+		S2
+	)
+	state := S0
+	lexeme := &bytes.Buffer{}
+	var start exprPosition
+	var stringStart exprPosition
+	for {
+		if state == S0 {
+			start = l.pos
+		}
+		r := l.readRune()
+		switch state {
+		case S0:
+			switch {
+			case unicode.IsSpace(r):
+				state = S0
+			case r == '\'':
+				stringStart = start
+				state = S1
+			case r == ',':
+				if !l.send(Token{Symbol: SymbolComma, Text: ",", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == ')':
+				if !l.send(Token{Symbol: SymbolRightParenthesis, Text: ")", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == 0:
+				l.send(Token{Symbol: SymbolEnd, Start: start, End: start})
+				return nil
+			default:
+				l.send(Token{
+					Symbol: SymbolError,
+					Text: fmt.Sprintf(
+						"unexpected character '%c' while expecting start of string, "+
+							"comma or right parenthesis", r,
+					),
+					Start: start,
+					End:   l.pos,
+				})
+				return nil
+			}
+		case S1:
+			switch r {
+			case '\'':
+				if !l.send(Token{
+					Symbol: SymbolString,
+					Text:   lexeme.String(),
+					Start:  stringStart,
+					End:    l.pos,
+				}) {
+					return nil
+				}
+				return dispatchState
+			case '\\':
+				state = S2
+			case 0:
+				l.send(Token{
+					Symbol: SymbolError,
+					Text:   "unterminated string",
+					Start:  stringStart,
+					End:    l.pos,
+				})
+				return nil
+			default:
+				lexeme.WriteRune(r)
+				state = S1
+			}
+		case S2:
+			switch r {
+			case '\'', '\\':
+				lexeme.WriteRune(r)
+				state = S1
+			case 0:
+				l.send(Token{
+					Symbol: SymbolError,
+					Text:   "unterminated string",
+					Start:  stringStart,
+					End:    l.pos,
+				})
+				return nil
+			default:
+				l.send(Token{
+					Symbol: SymbolError,
+					Text: fmt.Sprintf(
+						"unknown escape sequence '\\%c', valid escape sequences are "+
+							"'\\'' for '\\'' and '\\\\' for '\\'", r,
+					),
+					Start: l.prev,
+					End:   l.pos,
+				})
+				return nil
+			}
+		}
+	}
+}
+
+// lexBracket scans the next token while recognizing the contents of an array filtering segment: a
+// lone asterisk for a wildcard, a key and a glob value for a keyed filter, and the colon and right
+// bracket that separate and close them.
+func lexBracket(l *Lexer) stateFn {
+	type State int
+	const (
+		B0 State = iota
+		B1
+		B2
+		B3
+	)
+	state := B0
+	lexeme := &bytes.Buffer{}
+	var start exprPosition
+	var wordStart exprPosition
+	var stringStart exprPosition
+	for {
+		if state == B0 {
+			start = l.pos
+		}
+		r := l.readRune()
+		switch state {
+		case B0:
+			switch {
+			case unicode.IsSpace(r):
+				state = B0
+			case r == ':':
+				if !l.send(Token{Symbol: SymbolColon, Text: ":", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == ']':
+				if !l.send(Token{Symbol: SymbolRightBracket, Text: "]", Start: start, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			case r == '\'':
+				stringStart = start
+				state = B2
+			case isBracketWordRune(r):
+				wordStart = start
+				lexeme.WriteRune(r)
+				state = B1
+			case r == 0:
+				l.send(Token{Symbol: SymbolEnd, Start: start, End: start})
+				return nil
+			default:
+				l.send(Token{
+					Symbol: SymbolError,
+					Text: fmt.Sprintf(
+						"unexpected character '%c' while expecting start of word, "+
+							"quote, colon or right bracket", r,
+					),
+					Start: start,
+					End:   l.pos,
+				})
+				return nil
+			}
+		case B1:
+			switch {
+			case isBracketWordRune(r):
+				lexeme.WriteRune(r)
+				state = B1
+			default:
+				l.unreadRune()
+				text := lexeme.String()
+				symbol := SymbolIdentifier
+				if text == "*" {
+					symbol = SymbolStar
+				}
+				if !l.send(Token{Symbol: symbol, Text: text, Start: wordStart, End: l.pos}) {
+					return nil
+				}
+				return dispatchState
+			}
+		case B2:
+			switch r {
+			case '\'':
+				if !l.send(Token{
+					Symbol: SymbolString,
+					Text:   lexeme.String(),
+					Start:  stringStart,
+					End:    l.pos,
+				}) {
+					return nil
+				}
+				return dispatchState
+			case '\\':
+				state = B3
+			case 0:
+				l.send(Token{
+					Symbol: SymbolError,
+					Text:   "unterminated string",
+					Start:  stringStart,
+					End:    l.pos,
+				})
+				return nil
+			default:
+				lexeme.WriteRune(r)
+				state = B2
+			}
+		case B3:
+			switch r {
+			case '\'', '\\':
+				lexeme.WriteRune(r)
+				state = B2
+			case 0:
+				l.send(Token{
+					Symbol: SymbolError,
+					Text:   "unterminated string",
+					Start:  stringStart,
+					End:    l.pos,
+				})
+				return nil
+			default:
+				l.send(Token{
+					Symbol: SymbolError,
+					Text: fmt.Sprintf(
+						"unknown escape sequence '\\%c', valid escape sequences are "+
+							"'\\'' for '\\'' and '\\\\' for '\\'", r,
+					),
+					Start: l.prev,
+					End:   l.pos,
+				})
+				return nil
+			}
+		}
+	}
+}
+
+// isBracketWordRune reports whether the given rune can be part of an unquoted key or value
+// inside an array filtering segment. Besides letters, digits and underscore, it also accepts the
+// hyphen and the asterisk glob character that are common in identifiers like `CPU-*`.
+func isBracketWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '*'
+}
+
+// errorf creates a syntax error located between the given start and end positions.
+func (l *Lexer) errorf(start, end exprPosition, format string, args ...any) error {
+	return &SyntaxError{
+		Source:  l.source,
+		Line:    start.Line,
+		Column:  start.Column,
+		Start:   start.Offset,
+		End:     end.Offset,
+		message: fmt.Sprintf(format, args...),
+	}
+}
+
+// readRune reads the next rune from the source and updates the current position accordingly. It
+// returns zero when the end of the source has been reached.
+func (l *Lexer) readRune() rune {
+	l.prev = l.pos
+	r, size, err := l.buffer.ReadRune()
+	if errors.Is(err, io.EOF) {
+		return 0
+	}
+	if err != nil {
+		l.logger.Error(
+			"Unexpected error while reading rune",
+			"error", err,
+		)
+		return 0
+	}
+	l.pos.Offset += size
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+	} else {
+		l.pos.Column++
+	}
+	return r
+}
+
+// unreadRune undoes the last call to readRune, restoring the position to what it was before that
+// call. It can only be used once after each call to readRune.
+func (l *Lexer) unreadRune() {
+	err := l.buffer.UnreadRune()
+	if err != nil {
+		l.logger.Error(
+			"Unexpected error while unreading rune",
+			"error", err,
+		)
+		return
+	}
+	l.pos = l.prev
+}
@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package filter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// benchmarkSource builds a large filter expression, a conjunction of the given number of terms,
+// used to compare the cost of scanning with and without the synchronization that keeps SetMode in
+// step with FetchToken.
+func benchmarkSource(terms int) string {
+	parts := make([]string, terms)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("(eq,resourcePools/field%d/description,'value %d')", i, i)
+	}
+	return strings.Join(parts, ";")
+}
+
+// BenchmarkLexerFetchToken measures the cost of scanning through FetchToken, the synchronized, pull
+// based interface used by the parser. Each call hands the scanning goroutine permission to produce
+// exactly one more token, which is what keeps a call to SetMode synchronized with the token that it
+// affects. Like the parser, it switches to the values mode after the second comma of a term and
+// back to the default mode after its closing parenthesis.
+func BenchmarkLexerFetchToken(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	source := benchmarkSource(500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lexer, err := NewLexer().SetLogger(logger).SetSource(source).Build()
+		if err != nil {
+			b.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		lexer.Start(ctx)
+		commas := 0
+		for {
+			token, err := lexer.FetchToken()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if token.Symbol == SymbolEnd {
+				break
+			}
+			switch token.Symbol {
+			case SymbolComma:
+				commas++
+				if commas == 2 {
+					lexer.SetMode(exprValuesMode)
+				}
+			case SymbolRightParenthesis:
+				commas = 0
+				lexer.SetMode(exprDefaultMode)
+			}
+		}
+		cancel()
+	}
+}
+
+// BenchmarkLexerTokensChannel measures the cost of scanning through Tokens, the free running
+// channel intended for tools that tokenize without driving a parser. It never waits for
+// permission to continue, so it isolates the overhead of the channel and the goroutine from the
+// synchronization overhead that FetchToken adds on top of them.
+func BenchmarkLexerTokensChannel(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	source := benchmarkSource(500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lexer, err := NewLexer().SetLogger(logger).SetSource(source).Build()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for token := range lexer.Tokens() {
+			if token.Symbol == SymbolError {
+				b.Fatal(token.Text)
+			}
+			if token.Symbol == SymbolEnd {
+				break
+			}
+		}
+	}
+}
@@ -15,8 +15,8 @@ License.
 package filter
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"log/slog"
 	"strings"
 )
@@ -37,8 +37,9 @@ type Parser struct {
 // will be created each time that the Parse method is called.
 type parseTask struct {
 	logger *slog.Logger
-	lexer  *exprLexer
-	token  *exprToken
+	lexer  *Lexer
+	token  *Token
+	errors SyntaxErrorList
 }
 
 // NewParser creates a builder that can then be used to configure and create expression filter
@@ -68,31 +69,28 @@ func (b *ParserBuilder) Build() (result *Parser, err error) {
 	return
 }
 
-// Parse parses the give filter expression. If it succeeds it returns the object representing
-// that expression. If it fails it returns an error.
-func (p *Parser) Parse(text string) (expr *Expr, err error) {
-	// In order to simplify the rest of the parsing code we will panic when an error is
-	// detected. This recovers from those panics and converts them into regular errors.
-	defer func() {
-		fault := recover()
-		if fault != nil {
-			p.logger.Error(
-				"Failed to parse",
-				"text", text,
-				"error", err,
-			)
-			err = fault.(error)
-		}
-	}()
-
+// Parse parses the given filter expression. If it succeeds, and the expression doesn't contain any
+// syntax error, it returns the object representing that expression and a nil error. If the
+// expression contains one or more syntax errors it still returns the partial expression resulting
+// from the well formed terms, together with a non nil error that is a SyntaxErrorList containing
+// one entry per syntax error found.
+//
+// The context controls the goroutine that the lexer uses to scan the source: cancelling it, for
+// example because the caller isn't interested in the result any more, makes that goroutine
+// terminate instead of leaking, and makes Parse return the error of the context. The goroutine is
+// also stopped when Parse returns.
+func (p *Parser) Parse(ctx context.Context, text string) (expr *Expr, err error) {
 	// Create the lexer:
-	lexer, err := newExprLexer().
+	lexer, err := NewLexer().
 		SetLogger(p.logger).
 		SetSource(text).
 		Build()
 	if err != nil {
 		return
 	}
+	lexerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lexer.Start(lexerCtx)
 
 	// Create and run the parse task:
 	task := &parseTask{
@@ -100,193 +98,395 @@ func (p *Parser) Parse(text string) (expr *Expr, err error) {
 		lexer:  lexer,
 	}
 	expr = task.parseExpr()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+		return
+	}
+	if len(task.errors) > 0 {
+		p.logger.Error(
+			"Failed to parse",
+			"text", text,
+			"errors", task.errors,
+		)
+		err = task.errors
+	}
 	return
 }
 
+// parseExpr parses a whole filter expression, which is a disjunction of one or more conjunctions,
+// the lowest precedence level of the grammar, followed by the end of the input.
 func (t *parseTask) parseExpr() *Expr {
-	var terms []*Term
+	expr, ok := t.parseOr()
+	if !ok {
+		expr = &Expr{}
+	}
+	if !t.checkToken(SymbolEnd) {
+		t.reportExpected(SymbolEnd)
+	}
+	return expr
+}
+
+// parseOr parses a disjunction of one or more conjunctions separated by `|`.
+func (t *parseTask) parseOr() (expr *Expr, ok bool) {
+	var operands []*Expr
 	for {
-		term := t.parseTerm()
-		terms = append(terms, term)
-		if t.checkToken(exprSymbolSemicolon) {
+		operand, operandOk := t.parseAnd()
+		if operandOk {
+			operands = append(operands, operand)
+		}
+		if t.checkToken(SymbolPipe) {
 			t.fetchToken()
 			continue
 		}
-		if t.checkToken(exprSymbolEnd) {
-			break
+		break
+	}
+	if len(operands) == 0 {
+		return nil, false
+	}
+	if len(operands) == 1 {
+		return operands[0], true
+	}
+	return &Expr{Or: operands}, true
+}
+
+// parseAnd parses a conjunction of one or more primary expressions separated by `;`. When every
+// operand turns out to be a plain term, with no disjunction, negation or explicit grouping, it
+// returns the legacy shape used before boolean composition was introduced, so that code written
+// against that shape keeps working unchanged.
+func (t *parseTask) parseAnd() (expr *Expr, ok bool) {
+	var operands []*Expr
+	for {
+		operand, operandOk := t.parsePrimary()
+		if operandOk {
+			operands = append(operands, operand)
+		}
+		if t.checkToken(SymbolSemicolon) {
+			t.fetchToken()
+			continue
 		}
-		panic(fmt.Errorf(
-			"unexpected token '%s' while expecting semicolon or end of input",
-			t.currentToken(),
-		))
+		break
 	}
-	return &Expr{
-		Terms: terms,
+	if len(operands) == 0 {
+		return nil, false
+	}
+	if len(operands) == 1 {
+		return operands[0], true
+	}
+	if terms, plain := plainTerms(operands); plain {
+		return &Expr{Terms: terms}, true
 	}
+	return &Expr{And: operands}, true
 }
 
-func (t *parseTask) parseTerm() *Term {
-	t.consumeToken(exprSymbolLeftParenthesis)
-	operator := t.parseOperator()
-	t.consumeToken(exprSymbolComma)
-	path := t.parsePath()
-	t.consumeToken(exprSymbolComma)
+// plainTerms returns the list of terms contained in the given operands, and true, if every one of
+// them is a plain term with no boolean composition of its own.
+func plainTerms(operands []*Expr) (terms []*Term, ok bool) {
+	terms = make([]*Term, len(operands))
+	for i, operand := range operands {
+		if operand.Term == nil {
+			return nil, false
+		}
+		terms[i] = operand.Term
+	}
+	return terms, true
+}
+
+// parsePrimary parses the operand with the highest precedence in the grammar: a negation, a
+// parenthesized sub-expression used for grouping, or a single term. A left parenthesis starts a
+// term when it is immediately followed by an identifier, the name of the operator, and starts a
+// grouped sub-expression otherwise, so a single token of lookahead after the parenthesis is enough
+// to tell them apart.
+func (t *parseTask) parsePrimary() (expr *Expr, ok bool) {
+	if t.checkToken(SymbolBang) {
+		t.fetchToken()
+		operand, operandOk := t.parsePrimary()
+		if !operandOk {
+			return nil, false
+		}
+		return &Expr{Not: operand}, true
+	}
+	if !t.consumeToken(SymbolLeftParenthesis) {
+		return nil, false
+	}
+	if t.checkToken(SymbolIdentifier) {
+		return t.parseTerm()
+	}
+	inner, innerOk := t.parseOr()
+	if !innerOk {
+		t.consumeClosingParenthesis()
+		return nil, false
+	}
+	if !t.consumeToken(SymbolRightParenthesis) {
+		return nil, false
+	}
+	return &Expr{Group: inner}, true
+}
+
+// parseTerm parses a single filter term, assuming that parsePrimary has already consumed its
+// opening parenthesis and checked that the current token is the identifier of the operator.
+func (t *parseTask) parseTerm() (expr *Expr, ok bool) {
+	operator, operatorOk := t.parseOperator()
+	if !operatorOk {
+		t.consumeClosingParenthesis()
+		return nil, false
+	}
+	if !t.consumeToken(SymbolComma) {
+		t.consumeClosingParenthesis()
+		return nil, false
+	}
+	path, pathOk := t.parsePath()
+	if !pathOk {
+		t.consumeClosingParenthesis()
+		return nil, false
+	}
+	if !t.consumeToken(SymbolComma) {
+		t.consumeClosingParenthesis()
+		return nil, false
+	}
 	t.lexer.SetMode(exprValuesMode)
-	values := t.parseOptionalValues()
+	values, valuesOk := t.parseOptionalValues()
 	t.lexer.SetMode(exprDefaultMode)
-	t.consumeToken(exprSymbolRightParenthesis)
-	return &Term{
-		Operator: operator,
-		Path:     path,
-		Values:   values,
+	if !valuesOk {
+		t.consumeClosingParenthesis()
+		return nil, false
 	}
+	if !t.consumeToken(SymbolRightParenthesis) {
+		return nil, false
+	}
+	return &Expr{
+		Term: &Term{
+			Operator: operator,
+			Path:     path,
+			Values:   values,
+		},
+	}, true
 }
 
-func (t *parseTask) parseOperator() Operator {
-	name := t.parseIdentifier()
+// consumeClosingParenthesis consumes a right parenthesis left behind by a failed sync, so that a
+// malformed term or grouped sub-expression that was resynchronized on its own closing parenthesis
+// doesn't also produce a spurious "expected end of input" error right after it.
+func (t *parseTask) consumeClosingParenthesis() {
+	if t.checkToken(SymbolRightParenthesis) {
+		t.fetchToken()
+	}
+}
+
+func (t *parseTask) parseOperator() (operator Operator, ok bool) {
+	token := t.currentToken()
+	name := token.Text
+	if !t.consumeToken(SymbolIdentifier) {
+		return
+	}
 	switch strings.ToLower(name) {
 	case "cont":
-		return Cont
+		return Cont, true
 	case "eq":
-		return Eq
+		return Eq, true
 	case "gt":
-		return Gt
+		return Gt, true
 	case "gte":
-		return Gte
+		return Gte, true
 	case "in":
-		return In
+		return In, true
 	case "lt":
-		return Lt
+		return Lt, true
 	case "lte":
-		return Gt
+		return Gt, true
 	case "ncont":
-		return Ncont
+		return Ncont, true
 	case "neq":
-		return Neq
+		return Neq, true
 	case "nin":
-		return Nin
+		return Nin, true
 	default:
-		panic(fmt.Errorf("unknown operator '%s'", name))
+		t.errorf(token, "unknown operator '%s'", name)
+		t.sync(SymbolSemicolon, SymbolPipe, SymbolRightParenthesis)
+		return
 	}
 }
 
-func (t *parseTask) parsePath() []string {
-	var segments []string
+func (t *parseTask) parsePath() (segments []PathSegment, ok bool) {
 	for {
-		segment := t.parseIdentifier()
-		segments = append(segments, segment)
-		if t.checkToken(exprSymbolSlash) {
+		name, idOk := t.parseIdentifier()
+		if !idOk {
+			return nil, false
+		}
+		segments = append(segments, PathSegment{Name: name})
+		if t.checkToken(SymbolLeftBracket) {
+			// Switch to bracket mode before fetching the token that follows the left
+			// bracket, since SetMode is only safe to call between a FetchToken call and
+			// the next one, and that next call is the one right below.
+			t.lexer.SetMode(exprBracketMode)
+			t.fetchToken()
+			segment, segOk := t.parseBracketSegment()
+			if !segOk {
+				return nil, false
+			}
+			segments = append(segments, segment)
+		}
+		if t.checkToken(SymbolSlash) {
 			t.fetchToken()
 			continue
 		}
-		if t.checkToken(exprSymbolComma) {
-			break
+		if t.checkToken(SymbolComma) {
+			return segments, true
+		}
+		t.reportExpected(SymbolLeftBracket, SymbolSlash, SymbolComma)
+		return nil, false
+	}
+}
+
+// parseBracketSegment parses the contents of an array filtering segment, from right after the
+// opening bracket to right after the closing bracket. The caller has already switched the lexer to
+// bracket mode before fetching the first token of the segment.
+func (t *parseTask) parseBracketSegment() (segment PathSegment, ok bool) {
+	switch {
+	case t.checkToken(SymbolStar):
+		t.fetchToken()
+		segment = PathSegment{Wildcard: true}
+	default:
+		key, keyOk := t.parseIdentifier()
+		if !keyOk {
+			t.lexer.SetMode(exprDefaultMode)
+			return PathSegment{}, false
+		}
+		if !t.consumeToken(SymbolColon) {
+			t.lexer.SetMode(exprDefaultMode)
+			return PathSegment{}, false
+		}
+		value, glob, valOk := t.parseBracketValue()
+		if !valOk {
+			t.lexer.SetMode(exprDefaultMode)
+			return PathSegment{}, false
 		}
-		panic(fmt.Errorf(
-			"unexpected token '%s' while expecting slash or comma",
-			t.currentToken().Text,
-		))
+		segment = PathSegment{Key: key, Value: value, Glob: glob}
 	}
-	return segments
+	// Peek at the next token before leaving bracket mode, so that the closing bracket is
+	// recognized using the bracket mode rules instead of the default ones.
+	t.checkToken(SymbolRightBracket)
+	t.lexer.SetMode(exprDefaultMode)
+	if !t.consumeToken(SymbolRightBracket) {
+		return PathSegment{}, false
+	}
+	return segment, true
 }
 
-func (t *parseTask) parseIdentifier() string {
+// parseBracketValue parses the value of a keyed array filtering segment, which can either be a
+// quoted string or a bare word. The returned glob flag is true when the value contains the `*`
+// wildcard character.
+func (t *parseTask) parseBracketValue() (value string, glob bool, ok bool) {
 	token := t.currentToken()
-	t.consumeToken(exprSymbolIdentifier)
-	return token.Text
+	switch token.Symbol {
+	case SymbolString:
+		t.consumeToken(SymbolString)
+		return token.Text, false, true
+	case SymbolIdentifier:
+		t.consumeToken(SymbolIdentifier)
+		return token.Text, strings.Contains(token.Text, "*"), true
+	default:
+		t.reportExpected(SymbolString, SymbolIdentifier)
+		return "", false, false
+	}
 }
 
-func (t *parseTask) parseOptionalValues() []any {
-	if t.checkToken(exprSymbolRightParenthesis) {
-		return []any{}
+func (t *parseTask) parseIdentifier() (name string, ok bool) {
+	token := t.currentToken()
+	if !t.consumeToken(SymbolIdentifier) {
+		return "", false
 	}
-	if t.checkToken(exprSymbolString) {
+	return token.Text, true
+}
+
+func (t *parseTask) parseOptionalValues() ([]any, bool) {
+	if t.checkToken(SymbolRightParenthesis) {
+		return []any{}, true
+	}
+	if t.checkToken(SymbolString) {
 		return t.parseValues()
 	}
-	panic(fmt.Errorf(
-		"unexpected token '%s' while expecting value or right parenthesis",
-		t.currentToken().Text,
-	))
+	t.reportExpected(SymbolString, SymbolRightParenthesis)
+	return nil, false
 }
 
-func (t *parseTask) parseValues() []any {
-	var values []any
+func (t *parseTask) parseValues() (values []any, ok bool) {
 	for {
-		value := t.parseValue()
+		value, valOk := t.parseValue()
+		if !valOk {
+			return nil, false
+		}
 		values = append(values, value)
-		if t.checkToken(exprSymbolComma) {
+		if t.checkToken(SymbolComma) {
 			t.fetchToken()
 			continue
 		}
-		if t.checkToken(exprSymbolRightParenthesis) {
-			break
+		if t.checkToken(SymbolRightParenthesis) {
+			return values, true
 		}
-		panic(fmt.Errorf(
-			"unexpected token '%s' while expecting comma or right parenthesis",
-			t.currentToken().Text,
-		))
+		t.reportExpected(SymbolComma, SymbolRightParenthesis)
+		return nil, false
 	}
-	return values
 }
 
-func (t *parseTask) parseValue() any {
+func (t *parseTask) parseValue() (value any, ok bool) {
 	token := t.currentToken()
-	t.consumeToken(exprSymbolString)
-	return token.Text
+	if !t.consumeToken(SymbolString) {
+		return nil, false
+	}
+	return token.Text, true
 }
 
 // currentToken resturns the current token, fetching it from the lexer if needed.
-func (t *parseTask) currentToken() *exprToken {
+func (t *parseTask) currentToken() *Token {
 	t.ensureToken()
 	return t.token
 }
 
-// fetchToken discard the current token and fetches a new one from the lexer.
+// fetchToken discards the current token and fetches a new one from the lexer. If the lexer finds
+// a lexical error it is recorded like any other syntax error and a synthetic end of input token is
+// used in its place, so that parsing stops instead of looping over the same invalid character. The
+// synthetic token is stamped with the position of the lexical error itself, instead of the zero
+// value, so that any later error reported against it, for example by reportExpected, points at the
+// real offending text instead of rendering a bogus line and column of zero. Any other error, which
+// comes from the cancellation of the context, also ends the token stream, but isn't recorded.
 func (t *parseTask) fetchToken() {
 	token, err := t.lexer.FetchToken()
 	if err != nil {
-		panic(err)
+		var position exprPosition
+		if syntaxErr, ok := err.(*SyntaxError); ok {
+			t.errors = append(t.errors, syntaxErr)
+			position = exprPosition{
+				Offset: syntaxErr.Start,
+				Line:   syntaxErr.Line,
+				Column: syntaxErr.Column,
+			}
+		}
+		token = &Token{
+			Symbol: SymbolEnd,
+			Start:  position,
+			End:    position,
+		}
 	}
 	t.token = token
 }
 
 // checkToken returns true if the current token has the given symbol.
-func (t *parseTask) checkToken(symbol exprSymbol) bool {
+func (t *parseTask) checkToken(symbol Symbol) bool {
 	t.ensureToken()
 	return t.token.Symbol == symbol
 }
 
-// consumeToken checks that the symbol of the current token and then discards it, so that the next
-// time that a token is needed a new one will be fetched from the lexer. If the symbol is not the
-// given one then it panics.
-func (t *parseTask) consumeToken(symbol exprSymbol) {
+// consumeToken checks the symbol of the current token and discards it, so that the next time that
+// a token is needed a new one will be fetched from the lexer. If the symbol isn't the given one it
+// reports a syntax error, resynchronizes at the next recovery point and returns false, instead of
+// aborting the whole parse.
+func (t *parseTask) consumeToken(symbol Symbol) bool {
 	t.ensureToken()
 	if t.token.Symbol != symbol {
-		var expected string
-		switch symbol {
-		case exprSymbolEnd:
-			expected = "end of input"
-		case exprSymbolLeftParenthesis:
-			expected = "left parenthesis"
-		case exprSymbolRightParenthesis:
-			expected = "right parenthesis"
-		case exprSymbolIdentifier:
-			expected = "identifier"
-		case exprSymbolComma:
-			expected = "comma"
-		case exprSymbolSlash:
-			expected = "slash"
-		case exprSymbolSemicolon:
-			expected = "semicolon"
-		case exprSymbolString:
-			expected = "string"
-		}
-		panic(fmt.Errorf(
-			"unexpected token '%s' while expecting %s",
-			t.token.Text, expected,
-		))
+		t.reportExpected(symbol)
+		return false
 	}
 	t.token = nil
+	return true
 }
 
 // ensureToken makes sure the current token is populated, fetching it from the lexer if needed.
@@ -295,3 +495,48 @@ func (t *parseTask) ensureToken() {
 		t.fetchToken()
 	}
 }
+
+// reportExpected records the syntax error reported when the current token isn't one of the given
+// expected symbols, and then resynchronizes the parser at the next semicolon, pipe, right
+// parenthesis or end of input, so that parsing of the rest of the expression can continue.
+func (t *parseTask) reportExpected(symbols ...Symbol) {
+	names := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		names[i] = symbol.String()
+	}
+	var expected string
+	switch len(names) {
+	case 1:
+		expected = names[0]
+	default:
+		expected = strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+	}
+	t.errorf(t.currentToken(), "expected %s, got %s", expected, t.currentToken())
+	t.sync(SymbolSemicolon, SymbolPipe, SymbolRightParenthesis)
+}
+
+// errorf records a syntax error located at the position of the given token. Unlike the error
+// helpers of earlier versions of this parser, it doesn't panic: the caller is responsible for
+// deciding how to continue, usually by calling sync.
+func (t *parseTask) errorf(token *Token, format string, args ...any) {
+	err := t.lexer.errorf(token.Start, token.End, format, args...).(*SyntaxError)
+	t.errors = append(t.errors, err)
+}
+
+// sync discards tokens until it finds one whose symbol is one of the given recovery symbols, or
+// the end of the input, without consuming that token. It is used to resynchronize the parser after
+// a syntax error, so that a single malformed term doesn't prevent the rest of the expression from
+// being parsed.
+func (t *parseTask) sync(to ...Symbol) {
+	for {
+		if t.checkToken(SymbolEnd) {
+			return
+		}
+		for _, symbol := range to {
+			if t.checkToken(symbol) {
+				return
+			}
+		}
+		t.fetchToken()
+	}
+}
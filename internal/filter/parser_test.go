@@ -0,0 +1,196 @@
+/*
+Copyright (c) 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package filter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2/dsl/core"
+	. "github.com/onsi/ginkgo/v2/dsl/table"
+	. "github.com/onsi/gomega"
+)
+
+// testdataErrorPattern matches the `/* ERROR "regexp" */` markers used by the files under
+// testdata to annotate the syntax errors that the parser is expected to report. Following the
+// convention used by the Go standard library parser, a marker is placed immediately before the
+// position where the error is expected, so that removing it leaves the offending token starting
+// exactly where the marker used to be.
+var testdataErrorPattern = regexp.MustCompile(`/\*\s*ERROR\s*"((?:[^"\\]|\\.)*)"\s*\*/`)
+
+// testdataError is one of the errors expected by a testdata file.
+type testdataError struct {
+	line    int
+	column  int
+	pattern *regexp.Regexp
+}
+
+// loadTestdataFile reads the given testdata file, extracts the expected errors from its markers
+// and returns the filter expression that remains once the markers have been removed.
+func loadTestdataFile(path string) (source string, expected []testdataError, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(string(data), "\n")
+	cleanLines := make([]string, len(lines))
+	for i, line := range lines {
+		removedRunes := 0
+		for _, match := range testdataErrorPattern.FindAllStringSubmatchIndex(line, -1) {
+			prefixRunes := len([]rune(line[:match[0]]))
+			var pattern *regexp.Regexp
+			pattern, err = regexp.Compile(line[match[2]:match[3]])
+			if err != nil {
+				return
+			}
+			expected = append(expected, testdataError{
+				line:    i + 1,
+				column:  prefixRunes - removedRunes + 1,
+				pattern: pattern,
+			})
+			removedRunes += len([]rune(line[match[0]:match[1]]))
+		}
+		cleanLines[i] = testdataErrorPattern.ReplaceAllString(line, "")
+	}
+	source = strings.Join(cleanLines, "\n")
+	return
+}
+
+// checkTestdataFile parses the filter expression contained in the given testdata file and checks
+// that the reported syntax errors match the `/* ERROR "regexp" */` markers, both by position and
+// by message.
+func checkTestdataFile(path string) {
+	source, expected, err := loadTestdataFile(path)
+	Expect(err).ToNot(HaveOccurred())
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	parser, err := NewParser().SetLogger(logger).Build()
+	Expect(err).ToNot(HaveOccurred())
+
+	var actual SyntaxErrorList
+	_, err = parser.Parse(context.Background(), source)
+	if err != nil {
+		list, ok := err.(SyntaxErrorList)
+		Expect(ok).To(BeTrue(), "Parse should fail with a SyntaxErrorList")
+		actual = list
+	}
+
+	Expect(actual).To(HaveLen(len(expected)), "Number of reported errors doesn't match")
+	for i, exp := range expected {
+		Expect(actual[i].Line).To(Equal(exp.line), "Line of error %d doesn't match", i)
+		Expect(actual[i].Column).To(Equal(exp.column), "Column of error %d doesn't match", i)
+		Expect(exp.pattern.MatchString(actual[i].Error())).To(
+			BeTrue(), "Error %d doesn't match pattern '%s': %s", i, exp.pattern, actual[i],
+		)
+	}
+}
+
+var _ = Describe("Parser", func() {
+	Describe("Error recovery", func() {
+		entries, err := filepath.Glob("testdata/*.src")
+		if err != nil {
+			panic(err)
+		}
+		for _, entry := range entries {
+			entry := entry
+			It(fmt.Sprintf("Reports the expected errors in '%s'", entry), func() {
+				checkTestdataFile(entry)
+			})
+		}
+	})
+
+	Describe("Array filtering segments", func() {
+		DescribeTable(
+			"Round trip",
+			func(source string) {
+				logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+				parser, err := NewParser().SetLogger(logger).Build()
+				Expect(err).ToNot(HaveOccurred())
+				expr, err := parser.Parse(context.Background(), source)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(expr.String()).To(Equal(source))
+			},
+			Entry("Wildcard segment", "(eq,resourcePools/resources[*]/description,'x')"),
+			Entry(
+				"Keyed segment",
+				"(eq,resourcePools/resources[resourceTypeID:CPU-*]/description,'x')",
+			),
+		)
+	})
+
+	Describe("Lexical errors", func() {
+		It("Doesn't panic and reports a real position for a bad escape sequence", func() {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			parser, err := NewParser().SetLogger(logger).Build()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = parser.Parse(context.Background(), `(eq,a,'bad\x');(eq,b,'y')`)
+			Expect(err).To(HaveOccurred())
+			list, ok := err.(SyntaxErrorList)
+			Expect(ok).To(BeTrue(), "Parse should fail with a SyntaxErrorList")
+			Expect(list).ToNot(BeEmpty())
+			for _, syntaxErr := range list {
+				Expect(syntaxErr.Line).To(BeNumerically(">", 0))
+				Expect(func() { _ = syntaxErr.Error() }).ToNot(Panic())
+			}
+		})
+
+		It("Doesn't panic and reports a real position for an unterminated string", func() {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			parser, err := NewParser().SetLogger(logger).Build()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = parser.Parse(context.Background(), `(eq,a,'unterminated)`)
+			Expect(err).To(HaveOccurred())
+			list, ok := err.(SyntaxErrorList)
+			Expect(ok).To(BeTrue(), "Parse should fail with a SyntaxErrorList")
+			Expect(list).ToNot(BeEmpty())
+			for _, syntaxErr := range list {
+				Expect(syntaxErr.Line).To(BeNumerically(">", 0))
+				Expect(func() { _ = syntaxErr.Error() }).ToNot(Panic())
+			}
+		})
+	})
+
+	Describe("Boolean composition", func() {
+		DescribeTable(
+			"Round trip",
+			func(source string) {
+				logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+				parser, err := NewParser().SetLogger(logger).Build()
+				Expect(err).ToNot(HaveOccurred())
+				expr, err := parser.Parse(context.Background(), source)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(expr.String()).To(Equal(source))
+			},
+			Entry("Single term", "(eq,myattr,'myvalue')"),
+			Entry("Plain conjunction", "(eq,a,'1');(eq,b,'2')"),
+			Entry("Disjunction", "(eq,a,'1')|(eq,b,'2')"),
+			Entry(
+				"Negated group",
+				"(eq,state,'UP');!((eq,vendor,'X');(eq,vendor,'Y'))",
+			),
+			Entry(
+				"Minimal parenthesization of a grouped disjunction",
+				"((eq,a,'1')|(eq,b,'2'));(eq,c,'3')",
+			),
+			Entry("Double negation", "!!(eq,a,'1')"),
+		)
+	})
+})
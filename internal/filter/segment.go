@@ -0,0 +1,52 @@
+/*
+Copyright (c) 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package filter
+
+// PathSegment represents one element of a selector path. Most segments just select a field by
+// name, but a segment that follows a field containing an array can additionally carry a wildcard
+// or a keyed filter that selects which elements of that array to descend into, for example the
+// `*` inside `resources[*]` or the `resourceTypeID:CPU-*` inside
+// `resources[resourceTypeID:CPU-*]`.
+type PathSegment struct {
+	// Name is the name of the field that this segment selects, for example `resourcePools` or
+	// `description`. It is empty for the wildcard and keyed filter segments that follow a field
+	// segment inside brackets.
+	Name string
+
+	// Wildcard is true for a segment that selects every element of the array selected by the
+	// previous segment.
+	Wildcard bool
+
+	// Key and Value are set for a segment that selects only the elements of the array selected
+	// by the previous segment whose Key field matches Value. Glob is true when Value should be
+	// interpreted as a glob pattern, identified by the presence of a `*` in the unquoted value,
+	// instead of compared for exact equality.
+	Key   string
+	Value string
+	Glob  bool
+}
+
+// String generates the canonical text representation of the segment, for example `resourcePools`,
+// `[*]` or `[resourceTypeID:CPU-*]`.
+func (s PathSegment) String() string {
+	switch {
+	case s.Wildcard:
+		return "[*]"
+	case s.Key != "":
+		return "[" + s.Key + ":" + s.Value + "]"
+	default:
+		return s.Name
+	}
+}
@@ -34,8 +34,9 @@ type exprLexerBuilder struct {
 // exprLexer is a lexical scanner for the field selector expression language. Don't create
 // instances of this type directly, use the newExprLexer function instead.
 type exprLexer struct {
-	logger *slog.Logger
-	buffer *bytes.Buffer
+	logger    *slog.Logger
+	buffer    *bytes.Buffer
+	inBracket bool
 }
 
 // exprSymbol represents the terminal symbols of the field selector language.
@@ -44,8 +45,13 @@ type exprSymbol int
 const (
 	exprSymbolEnd exprSymbol = iota
 	exprSymbolIdentifier
+	exprSymbolString
 	exprSymbolComma
 	exprSymbolSlash
+	exprSymbolLeftBracket
+	exprSymbolRightBracket
+	exprSymbolColon
+	exprSymbolStar
 )
 
 // String generates a string representation of the terminal symbol.
@@ -55,10 +61,20 @@ func (s exprSymbol) String() string {
 		return "End"
 	case exprSymbolIdentifier:
 		return "Identifier"
+	case exprSymbolString:
+		return "String"
 	case exprSymbolComma:
 		return "Comma"
 	case exprSymbolSlash:
 		return "Slash"
+	case exprSymbolLeftBracket:
+		return "LeftBracket"
+	case exprSymbolRightBracket:
+		return "RightBracket"
+	case exprSymbolColon:
+		return "Colon"
+	case exprSymbolStar:
+		return "Star"
 	default:
 		return fmt.Sprintf("Unknown:%d", s)
 	}
@@ -77,7 +93,7 @@ func (t *exprToken) String() string {
 		return "Nil"
 	}
 	switch t.Symbol {
-	case exprSymbolIdentifier:
+	case exprSymbolIdentifier, exprSymbolString:
 		return fmt.Sprintf("%s:%s", t.Symbol, t.Text)
 	default:
 		return t.Symbol.String()
@@ -121,13 +137,18 @@ func (b *exprLexerBuilder) Build() (result *exprLexer, err error) {
 	return
 }
 
-// FetchToken fetches the next token from the source.
+// FetchToken fetches the next token from the source. Most characters are interpreted the same way
+// regardless of context, but `]`, `:`, `*`, `-` and the quote character that starts a string are
+// only recognized while scanning the contents of an array filtering segment, between a `[` and the
+// matching `]`.
 func (l *exprLexer) FetchToken() (token *exprToken, err error) {
 	type State int
 	const (
 		S0 State = iota
 		S1
 		S2
+		S3
+		S4
 	)
 	state := S0
 	lexeme := &bytes.Buffer{}
@@ -141,6 +162,9 @@ func (l *exprLexer) FetchToken() (token *exprToken, err error) {
 			case unicode.IsLetter(r) || r == '_':
 				lexeme.WriteRune(r)
 				state = S1
+			case l.inBracket && (r == '-' || r == '*'):
+				lexeme.WriteRune(r)
+				state = S1
 			case r == ',':
 				token = &exprToken{
 					Symbol: exprSymbolComma,
@@ -153,6 +177,28 @@ func (l *exprLexer) FetchToken() (token *exprToken, err error) {
 					Text:   "/",
 				}
 				return
+			case r == '[':
+				l.inBracket = true
+				token = &exprToken{
+					Symbol: exprSymbolLeftBracket,
+					Text:   "[",
+				}
+				return
+			case l.inBracket && r == ']':
+				l.inBracket = false
+				token = &exprToken{
+					Symbol: exprSymbolRightBracket,
+					Text:   "]",
+				}
+				return
+			case l.inBracket && r == ':':
+				token = &exprToken{
+					Symbol: exprSymbolColon,
+					Text:   ":",
+				}
+				return
+			case l.inBracket && r == '\'':
+				state = S3
 			case r == '~':
 				state = S2
 			case r == 0:
@@ -173,13 +219,21 @@ func (l *exprLexer) FetchToken() (token *exprToken, err error) {
 			case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
 				lexeme.WriteRune(r)
 				state = S1
+			case l.inBracket && (r == '-' || r == '*'):
+				lexeme.WriteRune(r)
+				state = S1
 			case r == '~':
 				state = S2
 			default:
 				l.unreadRune()
+				text := lexeme.String()
+				symbol := exprSymbolIdentifier
+				if l.inBracket && text == "*" {
+					symbol = exprSymbolStar
+				}
 				token = &exprToken{
-					Symbol: exprSymbolIdentifier,
-					Text:   lexeme.String(),
+					Symbol: symbol,
+					Text:   text,
 				}
 				return
 			}
@@ -194,10 +248,56 @@ func (l *exprLexer) FetchToken() (token *exprToken, err error) {
 			case 'a':
 				lexeme.WriteRune(',')
 				state = S0
+			case '2':
+				lexeme.WriteRune('[')
+				state = S0
+			case '3':
+				lexeme.WriteRune(']')
+				state = S0
+			case '4':
+				lexeme.WriteRune(':')
+				state = S0
+			case '5':
+				lexeme.WriteRune('*')
+				state = S0
 			default:
 				err = fmt.Errorf(
 					"unknown escape sequence '~%c', valid escape sequences "+
-						"are '~0' for '/', '~' for '/' and '~a' for ','",
+						"are '~0' for '~', '~1' for '/', '~a' for ',', "+
+						"'~2' for '[', '~3' for ']', '~4' for ':' and '~5' for '*'",
+					r,
+				)
+				return
+			}
+		case S3:
+			switch r {
+			case '\'':
+				token = &exprToken{
+					Symbol: exprSymbolString,
+					Text:   lexeme.String(),
+				}
+				return
+			case '\\':
+				state = S4
+			case 0:
+				err = errors.New("unterminated string")
+				return
+			default:
+				lexeme.WriteRune(r)
+				state = S3
+			}
+		case S4:
+			switch r {
+			case '\'', '\\':
+				lexeme.WriteRune(r)
+				state = S3
+			case 0:
+				err = errors.New("unterminated string")
+				return
+			default:
+				err = fmt.Errorf(
+					"unknown escape sequence '\\%c', valid escape sequences are "+
+						"'\\'' for '\\'' and '\\\\' for '\\'",
 					r,
 				)
 				return
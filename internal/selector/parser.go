@@ -0,0 +1,246 @@
+/*
+Copyright (c) 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// ParserBuilder contains the logic and data needed to create selector path parsers. Don't create
+// instances of this type directly, use the NewParser function instead.
+type ParserBuilder struct {
+	logger *slog.Logger
+}
+
+// Parser knows how to parse selector paths. Don't create instances of this type directly, use the
+// NewParser function instead.
+type Parser struct {
+	logger *slog.Logger
+}
+
+// parseTask contains the data needed to perform the parsing of one selector path. A new one will
+// be created each time that the Parse method is called.
+type parseTask struct {
+	logger *slog.Logger
+	lexer  *exprLexer
+	token  *exprToken
+	lexErr error
+}
+
+// NewParser creates a builder that can then be used to configure and create selector path parsers.
+// The builder can be reused to create multiple parsers with identical configuration.
+func NewParser() *ParserBuilder {
+	return &ParserBuilder{}
+}
+
+// SetLogger sets the logger that the parser will use to write log messages. This is mandatory.
+func (b *ParserBuilder) SetLogger(value *slog.Logger) *ParserBuilder {
+	b.logger = value
+	return b
+}
+
+// Build uses the configuration stored in the builder to create a new parser.
+func (b *ParserBuilder) Build() (result *Parser, err error) {
+	// Check parameters:
+	if b.logger == nil {
+		err = errors.New("logger is mandatory")
+		return
+	}
+
+	// Create and populate the object:
+	result = &Parser{
+		logger: b.logger,
+	}
+	return
+}
+
+// Parse parses the given selector path, for example `resourcePools/resources[resourceTypeID:CPU-*]/description`,
+// into the sequence of segments that compose it. Unlike the filter grammar, a selector path doesn't
+// carry an operator or values of its own, so a single syntax error aborts the parse instead of
+// being collected for later reporting.
+func (p *Parser) Parse(text string) (segments []PathSegment, err error) {
+	// Create the lexer:
+	lexer, err := newExprLexer().
+		SetLogger(p.logger).
+		SetSource(text).
+		Build()
+	if err != nil {
+		return
+	}
+
+	// Create and run the parse task:
+	task := &parseTask{
+		logger: p.logger,
+		lexer:  lexer,
+	}
+	segments, err = task.parsePath()
+	if err != nil {
+		p.logger.Error(
+			"Failed to parse",
+			"text", text,
+			"error", err,
+		)
+	}
+	return
+}
+
+// parsePath parses a whole selector path, a sequence of field segments optionally followed by a
+// bracket segment, separated by `/`, up to the end of the input.
+func (t *parseTask) parsePath() (segments []PathSegment, err error) {
+	for {
+		name, nameErr := t.parseIdentifier()
+		if nameErr != nil {
+			return nil, nameErr
+		}
+		segments = append(segments, PathSegment{Name: name})
+		if t.checkSymbol(exprSymbolLeftBracket) {
+			t.fetchToken()
+			var segment PathSegment
+			segment, err = t.parseBracketSegment()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment)
+		}
+		if t.checkSymbol(exprSymbolSlash) {
+			t.fetchToken()
+			continue
+		}
+		break
+	}
+	if !t.checkSymbol(exprSymbolEnd) {
+		return nil, t.expectedErr("expected slash or end of input, got %s", t.currentToken())
+	}
+	return segments, nil
+}
+
+// parseBracketSegment parses the contents of an array filtering segment, from right after the
+// opening bracket to right after the closing bracket.
+func (t *parseTask) parseBracketSegment() (segment PathSegment, err error) {
+	if t.checkSymbol(exprSymbolStar) {
+		t.fetchToken()
+		segment = PathSegment{Wildcard: true}
+	} else {
+		var key string
+		key, err = t.parseIdentifier()
+		if err != nil {
+			return
+		}
+		if !t.checkSymbol(exprSymbolColon) {
+			err = t.expectedErr("expected colon, got %s", t.currentToken())
+			return
+		}
+		t.fetchToken()
+		quoted := t.checkSymbol(exprSymbolString)
+		var value string
+		value, err = t.parseBracketValue()
+		if err != nil {
+			return
+		}
+		segment = PathSegment{Key: key, Value: value, Glob: !quoted && globValue(value)}
+	}
+	if !t.checkSymbol(exprSymbolRightBracket) {
+		err = t.expectedErr("expected right bracket, got %s", t.currentToken())
+		return
+	}
+	t.fetchToken()
+	return
+}
+
+// parseBracketValue parses the value of a keyed array filtering segment, which can either be a
+// quoted string or a bare word.
+func (t *parseTask) parseBracketValue() (value string, err error) {
+	token := t.currentToken()
+	switch token.Symbol {
+	case exprSymbolString, exprSymbolIdentifier:
+		t.fetchToken()
+		return token.Text, nil
+	default:
+		return "", t.expectedErr("expected string or identifier, got %s", token)
+	}
+}
+
+// globValue returns true when the given bracket value should be interpreted as a glob pattern,
+// identified by the presence of a `*` in it.
+func globValue(value string) bool {
+	for _, r := range value {
+		if r == '*' {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIdentifier parses a single identifier, the name of a path segment.
+func (t *parseTask) parseIdentifier() (name string, err error) {
+	token := t.currentToken()
+	if token.Symbol != exprSymbolIdentifier {
+		return "", t.expectedErr("expected identifier, got %s", token)
+	}
+	t.fetchToken()
+	return token.Text, nil
+}
+
+// expectedErr builds the error reported when the current token isn't one of the symbols that the
+// parser expected. If a lexical error was the actual reason why the current token looks wrong, for
+// example because an unterminated string forced a synthetic end of input, that error is reported
+// instead, since it points at the real problem.
+func (t *parseTask) expectedErr(format string, args ...any) error {
+	if t.lexErr != nil {
+		return t.lexErr
+	}
+	return fmt.Errorf(format, args...)
+}
+
+// currentToken returns the current token, fetching it from the lexer if needed. Once a lexical
+// error has been recorded it keeps returning a synthetic end of input token instead of asking the
+// lexer for more, so that the rest of the parser sees a consistent, terminated stream.
+func (t *parseTask) currentToken() *exprToken {
+	t.ensureToken()
+	return t.token
+}
+
+// checkSymbol returns true if the current token has the given symbol.
+func (t *parseTask) checkSymbol(symbol exprSymbol) bool {
+	t.ensureToken()
+	return t.token.Symbol == symbol
+}
+
+// fetchToken discards the current token so that the next time that a token is needed a new one
+// will be fetched from the lexer.
+func (t *parseTask) fetchToken() {
+	t.token = nil
+}
+
+// ensureToken makes sure the current token is populated, fetching it from the lexer if needed and
+// recording any lexical error so that it can be returned to the caller of Parse instead of being
+// mistaken for a well formed end of input.
+func (t *parseTask) ensureToken() {
+	if t.token != nil {
+		return
+	}
+	if t.lexErr != nil {
+		t.token = &exprToken{Symbol: exprSymbolEnd}
+		return
+	}
+	token, err := t.lexer.FetchToken()
+	if err != nil {
+		t.lexErr = err
+		token = &exprToken{Symbol: exprSymbolEnd}
+	}
+	t.token = token
+}
@@ -0,0 +1,69 @@
+/*
+Copyright (c) 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package selector
+
+import (
+	"io"
+	"log/slog"
+
+	. "github.com/onsi/ginkgo/v2/dsl/core"
+	. "github.com/onsi/ginkgo/v2/dsl/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parser", func() {
+	Describe("Round trip", func() {
+		DescribeTable(
+			"Renders the same text that was parsed",
+			func(source string) {
+				logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+				parser, err := NewParser().SetLogger(logger).Build()
+				Expect(err).ToNot(HaveOccurred())
+				segments, err := parser.Parse(source)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(PathString(segments)).To(Equal(source))
+			},
+			Entry("Single field", "myattr"),
+			Entry("Multiple fields", "resourcePools/description"),
+			Entry("Wildcard segment", "resourcePools/resources[*]/description"),
+			Entry(
+				"Keyed segment",
+				"resourcePools/resources[resourceTypeID:CPU-*]/description",
+			),
+			Entry("Escaped slash in field name", "my~1attr"),
+			Entry("Escaped tilde in field name", "my~0attr"),
+			Entry("Escaped bracket in field name", "my~2attr"),
+			Entry("Escaped colon in keyed segment value", "resources[key:a~4b]"),
+		)
+	})
+
+	Describe("Errors", func() {
+		It("Fails on an unknown escape sequence", func() {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			parser, err := NewParser().SetLogger(logger).Build()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = parser.Parse("my~xattr")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Fails when a bracket segment is never closed", func() {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			parser, err := NewParser().SetLogger(logger).Build()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = parser.Parse("resources[*")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,117 @@
+/*
+Copyright (c) 2023 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package selector
+
+import "strings"
+
+// PathSegment represents one element of a selector path. Most segments just select a field by
+// name, but a segment that follows a field containing an array can additionally carry a wildcard
+// or a keyed filter that selects which elements of that array to descend into, for example the
+// `*` inside `resources[*]` or the `resourceTypeID:CPU-*` inside
+// `resources[resourceTypeID:CPU-*]`.
+type PathSegment struct {
+	// Name is the name of the field that this segment selects, for example `resourcePools` or
+	// `description`. It is empty for the wildcard and keyed filter segments that follow a field
+	// segment inside brackets.
+	Name string
+
+	// Wildcard is true for a segment that selects every element of the array selected by the
+	// previous segment.
+	Wildcard bool
+
+	// Key and Value are set for a segment that selects only the elements of the array selected
+	// by the previous segment whose Key field matches Value. Glob is true when Value should be
+	// interpreted as a glob pattern, identified by the presence of a `*` in the unquoted value,
+	// instead of compared for exact equality.
+	Key   string
+	Value string
+	Glob  bool
+}
+
+// String generates the canonical text representation of the segment, for example `resourcePools`,
+// `[*]` or `[resourceTypeID:CPU-*]`. Unlike the path segments of the filter grammar, a field name
+// is escaped, because here it isn't delimited by commas and parentheses, so the characters that the
+// grammar gives a meaning to, `~`, `/`, `,`, `[`, `]` and `:`, would otherwise be indistinguishable
+// from the punctuation of the path itself. A `*` is only special inside a bracket segment, so it is
+// escaped in a field name but left alone in a key or a value, where the lexer already accepts it
+// unescaped as a glob character.
+func (s PathSegment) String() string {
+	switch {
+	case s.Wildcard:
+		return "[*]"
+	case s.Key != "":
+		return "[" + escapeBracketText(s.Key) + ":" + escapeBracketText(s.Value) + "]"
+	default:
+		return escapeFieldText(s.Name)
+	}
+}
+
+// escapeFieldText escapes the characters of the given field name that the selector grammar
+// reserves for its own punctuation, using the same `~` escape codes that the lexer accepts: `~0`
+// for `~`, `~1` for `/`, `~a` for `,`, `~2` for `[`, `~3` for `]`, `~4` for `:` and `~5` for `*`.
+func escapeFieldText(text string) string {
+	return escapeText(text, true)
+}
+
+// escapeBracketText escapes the characters of the given bracket key or value the same way that
+// escapeFieldText does, except that `*` is left unescaped, since the lexer already accepts it
+// unescaped there as the glob character of a keyed filter.
+func escapeBracketText(text string) string {
+	return escapeText(text, false)
+}
+
+// escapeText is the shared implementation of escapeFieldText and escapeBracketText.
+func escapeText(text string, escapeStar bool) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch r {
+		case '~':
+			b.WriteString("~0")
+		case '/':
+			b.WriteString("~1")
+		case ',':
+			b.WriteString("~a")
+		case '[':
+			b.WriteString("~2")
+		case ']':
+			b.WriteString("~3")
+		case ':':
+			b.WriteString("~4")
+		case '*':
+			if escapeStar {
+				b.WriteString("~5")
+			} else {
+				b.WriteRune(r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// PathString generates the canonical text representation of a path, joining its segments with
+// `/`, except that a bracket segment, the wildcard or keyed filter that follows a field name, is
+// never preceded by a slash, matching what the parser accepts.
+func PathString(segments []PathSegment) string {
+	var b strings.Builder
+	for i, segment := range segments {
+		if i > 0 && segment.Key == "" && !segment.Wildcard {
+			b.WriteString("/")
+		}
+		b.WriteString(segment.String())
+	}
+	return b.String()
+}